@@ -0,0 +1,156 @@
+package sgcm
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// encryptWriter adapts an AEADEncryptor to an io.WriteCloser, eliminating
+// the Next/Finalize bookkeeping every caller would otherwise have to do by
+// hand. This mirrors the pattern used by cipher.StreamWriter.
+type encryptWriter struct {
+	enc    AEADEncryptor
+	w      io.Writer
+	closed bool
+}
+
+// NewEncryptWriter calls enc.Initialize(nonce, ad), then returns an
+// io.WriteCloser that forwards each Write through enc.Next, writing the
+// resulting ciphertext to w. Close calls enc.Finalize and writes the
+// buffered ciphertext and tag; it must be called exactly once, after all
+// plaintext has been written.
+//
+// How much of that ciphertext actually reaches w before Close depends on
+// enc: with the pure-Go GCM path each Write emits ciphertext for every full
+// block already buffered, but with the hardware-accelerated path (the
+// common case for AES; see NewStreamingGCM) enc.Next only buffers, so every
+// Write is a no-op on w and the entire ciphertext is written by Close. In
+// that case this adapter offers no memory advantage over buffering the
+// whole message and calling enc.Finalize directly.
+func NewEncryptWriter(enc AEADEncryptor, w io.Writer, nonce, ad []byte) io.WriteCloser {
+	enc.Initialize(nonce, ad)
+	return &encryptWriter{enc: enc, w: w}
+}
+
+func (ew *encryptWriter) Write(p []byte) (int, error) {
+	if ew.closed {
+		return 0, errors.New("sgcm: write to closed EncryptWriter")
+	}
+	if _, err := ew.w.Write(ew.enc.Next(nil, p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (ew *encryptWriter) Close() error {
+	if ew.closed {
+		return nil
+	}
+	ew.closed = true
+	_, err := ew.w.Write(ew.enc.Finalize(nil))
+	return err
+}
+
+// decryptReader adapts an AEADDecryptor to an io.Reader, handling the
+// tag-boundary bookkeeping that AEADDecryptor.Finalize's doc comment warns
+// callers about: the last TagSize() bytes read from r are the tag, not
+// ciphertext, and so can't be fed to Next until it's known no more bytes
+// follow them.
+type decryptReader struct {
+	dec     AEADDecryptor
+	r       *bufio.Reader
+	tagSize int
+
+	// held is the most recent tagSize bytes read from r that have not yet
+	// been confirmed to be followed by more ciphertext.
+	held []byte
+	buf  []byte
+	done bool
+	err  error
+}
+
+// NewDecryptReader calls dec.Initialize(nonce, ad), then returns an
+// io.Reader that reads ciphertext from r, feeds everything but the trailing
+// TagSize() bytes through dec.Next, and authenticates the message via
+// dec.Finalize once r is exhausted, treating those trailing bytes as the
+// tag. Authentication failure is surfaced as an error from the Read call
+// that reaches EOF.
+//
+// With the hardware-accelerated GCM path (the common case for AES; see
+// NewStreamingGCM) dec.Next only buffers and the actual decryption happens
+// in dec.Finalize, so the first Read that returns plaintext blocks until r
+// is fully consumed and authenticated; this adapter then offers no memory
+// advantage over reading all of r and calling dec.Finalize directly.
+func NewDecryptReader(dec AEADDecryptor, r io.Reader, nonce, ad []byte) io.Reader {
+	dec.Initialize(nonce, ad)
+	// fill reads tagSize bytes at a time; route those through a bufio.Reader
+	// so that a raw source like an os.File or net.Conn isn't hit with one
+	// syscall per tagSize (12-16) bytes.
+	return &decryptReader{dec: dec, r: bufio.NewReader(r), tagSize: dec.TagSize()}
+}
+
+func (dr *decryptReader) Read(p []byte) (int, error) {
+	for len(dr.buf) == 0 {
+		if dr.err != nil {
+			return 0, dr.err
+		}
+		if dr.done {
+			return 0, io.EOF
+		}
+		if err := dr.fill(); err != nil {
+			dr.err = err
+			return 0, err
+		}
+	}
+
+	n := copy(p, dr.buf)
+	dr.buf = dr.buf[n:]
+	return n, nil
+}
+
+// fill reads the next chunk from r, releasing any previously-held bytes as
+// plaintext once it's known they weren't part of the tag, and appends the
+// resulting plaintext to dr.buf. Once r is exhausted, it authenticates the
+// message via dec.Finalize, appends any final plaintext to dr.buf, and sets
+// dr.done so the next empty Read reports io.EOF.
+func (dr *decryptReader) fill() error {
+	chunk := make([]byte, dr.tagSize)
+	n, err := io.ReadFull(dr.r, chunk)
+	switch err {
+	case nil:
+		// The full chunk was read; dr.held, if any, can't be part of the
+		// tag, so release it as ciphertext.
+		if len(dr.held) > 0 {
+			dr.buf = append(dr.buf, dr.dec.Next(nil, dr.held)...)
+		}
+		dr.held = chunk
+		return nil
+
+	case io.ErrUnexpectedEOF, io.EOF:
+		// r is exhausted. Only the last tagSize bytes of everything read
+		// so far (held, plus whatever was read just now) are the tag; any
+		// bytes before that in held are ciphertext still owed to Next.
+		combined := append(dr.held, chunk[:n]...)
+		if len(combined) < dr.tagSize {
+			return errors.New("sgcm: ciphertext is truncated")
+		}
+		split := len(combined) - dr.tagSize
+		if split > 0 {
+			dr.buf = append(dr.buf, dr.dec.Next(nil, combined[:split])...)
+		}
+		tag := combined[split:]
+		dr.held = nil
+
+		plain, err := dr.dec.Finalize(nil, tag)
+		if err != nil {
+			return err
+		}
+		dr.buf = append(dr.buf, plain...)
+		dr.done = true
+		return nil
+
+	default:
+		return err
+	}
+}
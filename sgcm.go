@@ -3,6 +3,7 @@ package sgcm
 import (
 	"crypto/cipher"
 	"crypto/subtle"
+	"errors"
 )
 
 // AEADEncryptor is an AEAD interface specialized for streaming
@@ -81,6 +82,30 @@ type gcmStreamer struct {
 	counter, tagMask [gcmBlockSize]byte
 	buf              []byte
 	verifyOnly       bool
+
+	// hw, if non-nil, is a hardware-accelerated AEAD obtained from the
+	// input cipher.Block's own gcmAble implementation (e.g. the AES-NI and
+	// PCLMULQDQ path in crypto/aes, or PMULL on arm64). Unlike the pure-Go
+	// path above, it only knows how to process a whole message at once, so
+	// when it's in play, Next just buffers and Finalize does a single
+	// accelerated Seal/Open over the buffered message. This is permitted
+	// by the AEADEncryptor/AEADDecryptor contract, which never guarantees
+	// that ciphertext is emitted before Finalize.
+	hw        cipher.AEAD
+	nonce, ad []byte
+
+	// tagSize is the number of bytes of the 16-byte GHASH tag that are
+	// actually used; see NewStreamingGCMWithTagSize.
+	tagSize int
+}
+
+// gcmAble mirrors the identically-named, unexported interface that
+// crypto/cipher uses to detect ciphers carrying their own optimized
+// implementation of GCM. Go interface satisfaction is structural, so
+// redeclaring the method here is enough to detect the same capability
+// without access to the unexported type.
+type gcmAble interface {
+	NewGCM(nonceSize, tagSize int) (cipher.AEAD, error)
 }
 
 type gcmEncryptor struct {
@@ -96,25 +121,84 @@ type gcmDecryptor struct {
 // size.
 //
 // The input cipher must a have a blocksize of 16 bytes, e.g AES-128.
+//
+// Memory use is bounded in the number of blocks buffered between calls to
+// Next, EXCEPT when cipher carries its own hardware-accelerated
+// implementation of GCM (true of crypto/aes on essentially every amd64,
+// arm64 and s390x build): in that case Next only buffers and the
+// accelerated Seal/Open runs once, in Finalize, so peak memory is
+// proportional to the size of the whole message. Use a cipher without such
+// an implementation, or NewStreamingGCMPureGo, if bounded memory matters
+// more than throughput.
 func NewStreamingGCM(cipher cipher.Block) (AEADEncryptor, AEADDecryptor, error) {
 	return NewStreamingGCMWithNonceSize(cipher, gcmStandardNonceSize)
 }
 
 // NewStreamingGCMWithNoncSize returns an AEADEncryptor and AEADDecryptor for
 // Galois counter mode with a non-standard nonce size.
+//
+// See NewStreamingGCM for the memory caveat that applies when cipher has a
+// hardware-accelerated implementation of GCM.
 func NewStreamingGCMWithNonceSize(cipher cipher.Block, size int) (AEADEncryptor, AEADDecryptor, error) {
-	g, err := NewGCMWithNonceSize(cipher, size)
+	return NewStreamingGCMWithNonceAndTagSize(cipher, size, gcmTagSize)
+}
+
+// NewStreamingGCMWithTagSize returns an AEADEncryptor and AEADDecryptor for
+// Galois counter mode with the standard (12 byte) nonce size and a truncated
+// tag of the given size, as permitted by NIST SP 800-38D. tagSize must be
+// between gcmMinimumTagSize (12) and gcmBlockSize (16) bytes, inclusive.
+//
+// See NewStreamingGCM for the memory caveat that applies when cipher has a
+// hardware-accelerated implementation of GCM.
+func NewStreamingGCMWithTagSize(cipher cipher.Block, tagSize int) (AEADEncryptor, AEADDecryptor, error) {
+	return NewStreamingGCMWithNonceAndTagSize(cipher, gcmStandardNonceSize, tagSize)
+}
+
+// NewStreamingGCMWithNonceAndTagSize returns an AEADEncryptor and
+// AEADDecryptor for Galois counter mode with a non-standard nonce size and a
+// truncated tag of the given size. tagSize must be between
+// gcmMinimumTagSize (12) and gcmBlockSize (16) bytes, inclusive.
+//
+// See NewStreamingGCM for the memory caveat that applies when cipher has a
+// hardware-accelerated implementation of GCM; use NewStreamingGCMPureGo
+// instead if that caveat is unacceptable.
+func NewStreamingGCMWithNonceAndTagSize(cipher cipher.Block, nonceSize, tagSize int) (AEADEncryptor, AEADDecryptor, error) {
+	return newStreamingGCM(cipher, nonceSize, tagSize, true)
+}
+
+// NewStreamingGCMPureGo is like NewStreamingGCMWithNonceAndTagSize, except
+// that it never uses cipher's own hardware-accelerated implementation of GCM
+// even if it has one. Use this to get NewStreamingGCM's bounded-memory
+// streaming guarantee with a cipher such as crypto/aes, at the cost of the
+// throughput the accelerated path would otherwise offer.
+func NewStreamingGCMPureGo(cipher cipher.Block, nonceSize, tagSize int) (AEADEncryptor, AEADDecryptor, error) {
+	return newStreamingGCM(cipher, nonceSize, tagSize, false)
+}
+
+func newStreamingGCM(cipher cipher.Block, nonceSize, tagSize int, allowHW bool) (AEADEncryptor, AEADDecryptor, error) {
+	if tagSize < gcmMinimumTagSize || tagSize > gcmBlockSize {
+		return nil, nil, errors.New("cipher: incorrect tag size given to GCM")
+	}
+
+	g, err := NewGCMWithNonceSize(cipher, nonceSize)
 	if err != nil {
 		return nil, nil, err
 	}
 	sg := &gcmStreamer{
-		*(g.(*gcm)),
-		gcmFieldElement{},
-		gcmFieldElement{},
-		[16]byte{},
-		[16]byte{},
-		nil,
-		false,
+		gcm:     *(g.(*gcm)),
+		tagSize: tagSize,
+	}
+
+	// Prefer the cipher's own hardware-accelerated implementation of GCM
+	// when it has one and the caller hasn't opted out: it's 5-10x faster
+	// than the pure-Go GHASH path below for typical AES-128/AES-256
+	// workloads.
+	if allowHW {
+		if able, ok := cipher.(gcmAble); ok {
+			if hw, err := able.NewGCM(nonceSize, tagSize); err == nil {
+				sg.hw = hw
+			}
+		}
 	}
 
 	enc := &gcmEncryptor{*sg}
@@ -123,7 +207,7 @@ func NewStreamingGCMWithNonceSize(cipher cipher.Block, size int) (AEADEncryptor,
 }
 
 func (sg *gcmStreamer) TagSize() int {
-	return gcmTagSize
+	return sg.tagSize
 }
 
 // Initialize sets up the state for streaming encryption/decryption in Galois
@@ -132,6 +216,14 @@ func (sg *gcmStreamer) Initialize(nonce, ad []byte) {
 	if len(nonce) != sg.nonceSize {
 		panic("cipher: incorrect nonce length given to GCM")
 	}
+
+	if sg.hw != nil {
+		sg.nonce = append(sg.nonce[:0], nonce...)
+		sg.ad = append(sg.ad[:0], ad...)
+		sg.buf = nil
+		return
+	}
+
 	for i := range sg.counter {
 		sg.counter[i] = 0
 	}
@@ -168,6 +260,13 @@ func (sg *gcmStreamer) finalizeAuth(t []byte) {
 // just outputs dst.
 func (enc *gcmEncryptor) Next(dst, src []byte) []byte {
 
+	if enc.hw != nil {
+		// On the hardware path no block is encrypted here: src is only
+		// buffered, and Finalize encrypts the whole message.
+		enc.buf = append(enc.buf, src...)
+		return dst
+	}
+
 	// Buffer plaintext fragment src and update the ciphertext length
 	// (enc.z.hight).
 	enc.buf = append(enc.buf, src...)
@@ -202,6 +301,10 @@ func (enc *gcmEncryptor) Next(dst, src []byte) []byte {
 // it.
 func (enc *gcmEncryptor) Finalize(dst []byte) []byte {
 
+	if enc.hw != nil {
+		return enc.hw.Seal(dst, enc.nonce, enc.buf, enc.ad)
+	}
+
 	// Encrypt the last chunk and update the authenticator.
 	c := make([]byte, len(enc.buf))
 	enc.cipher.Encrypt(enc.counter[:], enc.counter[:])
@@ -209,17 +312,24 @@ func (enc *gcmEncryptor) Finalize(dst []byte) []byte {
 	enc.update(&enc.y, c)
 	dst = append(dst, c...)
 
-	// Finalize the authenticator.
+	// Finalize the authenticator, truncating the tag to the configured
+	// size.
 	t := make([]byte, gcmTagSize)
 	enc.finalizeAuth(t)
 
-	dst = append(dst, t...)
+	dst = append(dst, t[:enc.tagSize]...)
 	return dst
 }
 
 // InitializeVerifyOnly sets up decryption state in "authenticate only" mode.
 // This means that the ciphertext is processed like normal, except that no
 // plaintext is output by Next() or Finalize().
+//
+// This saves the cost of decrypting on the pure-Go path (see
+// NewStreamingGCM), but not when cipher has a hardware-accelerated
+// implementation of GCM: cipher.AEAD's Open doesn't expose a way to
+// authenticate without also decrypting, so Finalize still decrypts the
+// whole message there before discarding the plaintext.
 func (dec *gcmDecryptor) InitializeVerifyOnly(nonce, ad []byte) {
 	dec.Initialize(nonce, ad)
 	dec.verifyOnly = true
@@ -229,6 +339,14 @@ func (dec *gcmDecryptor) InitializeVerifyOnly(nonce, ad []byte) {
 // decrypts, and appends the result to dst.
 func (dec *gcmDecryptor) Next(dst, src []byte) []byte {
 
+	if dec.hw != nil {
+		// On the hardware path no block is decrypted here: src is only
+		// buffered, and Finalize authenticates and decrypts the whole
+		// message.
+		dec.buf = append(dec.buf, src...)
+		return dst
+	}
+
 	// Buffer ciphertext fragment src and update the ciphertext length
 	// (dec.z.hight).
 	dec.buf = append(dec.buf, src...)
@@ -261,6 +379,25 @@ func (dec *gcmDecryptor) Next(dst, src []byte) []byte {
 // it is equal to the tag provided by the caller. If the ciphertext is
 // authentic, then it outputs dst; otherwise it outputs nil and an error.
 func (dec *gcmDecryptor) Finalize(dst, tag []byte) ([]byte, error) {
+	if dec.hw != nil {
+		ciphertext := make([]byte, 0, len(dec.buf)+len(tag))
+		ciphertext = append(ciphertext, dec.buf...)
+		ciphertext = append(ciphertext, tag...)
+
+		// cipher.AEAD has no way to authenticate without also decrypting, so
+		// Open runs unconditionally here; in verifyOnly mode the resulting
+		// plaintext is simply discarded below instead of being copied into
+		// dst (see InitializeVerifyOnly).
+		p, err := dec.hw.Open(nil, dec.nonce, ciphertext, dec.ad)
+		if err != nil {
+			return nil, errOpen
+		}
+		if dec.verifyOnly {
+			return dst, nil
+		}
+		return append(dst, p...), nil
+	}
+
 	// Update the authenticator state with the remaining fragment.
 	dec.update(&dec.y, dec.buf)
 
@@ -272,9 +409,11 @@ func (dec *gcmDecryptor) Finalize(dst, tag []byte) ([]byte, error) {
 		dst = append(dst, p...)
 	}
 
-	// Finalize the authenticator.
+	// Finalize the authenticator, truncating the tag to the configured
+	// size.
 	t := make([]byte, gcmTagSize)
 	dec.finalizeAuth(t)
+	t = t[:dec.tagSize]
 
 	// Check validity of the tag.
 	//
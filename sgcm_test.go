@@ -0,0 +1,222 @@
+package sgcm
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"testing"
+)
+
+// noHardwareBlock wraps a cipher.Block without exposing any gcmAble
+// implementation the underlying value might have (interface embedding only
+// promotes the embedded interface's own methods), forcing gcmStreamer onto
+// the pure-Go GHASH path regardless of what the build's CPU supports.
+type noHardwareBlock struct {
+	cipher.Block
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// splitInto breaks b into fragments of at most n bytes, to exercise Next's
+// buffering across several calls instead of a single one.
+func splitInto(b []byte, n int) [][]byte {
+	var out [][]byte
+	for len(b) > 0 {
+		end := n
+		if end > len(b) {
+			end = len(b)
+		}
+		out = append(out, b[:end])
+		b = b[end:]
+	}
+	return out
+}
+
+// TestHardwareAndPureGoAgree checks that the hardware-accelerated and
+// pure-Go GHASH paths in gcmStreamer produce identical ciphertext and tags
+// for the same key, nonce, associated data and plaintext, and that both
+// agree with crypto/cipher's own GCM.
+func TestHardwareAndPureGoAgree(t *testing.T) {
+	key := randomBytes(t, 16)
+	nonce := randomBytes(t, gcmStandardNonceSize)
+	ad := []byte("associated data")
+	pt := bytes.Repeat([]byte("sgcm hardware vs pure go "), 50)
+
+	hwBlock, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pureBlock := noHardwareBlock{hwBlock}
+
+	seal := func(block cipher.Block) []byte {
+		enc, _, err := NewStreamingGCM(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		enc.Initialize(nonce, ad)
+		var ct []byte
+		for _, frag := range splitInto(pt, 7) {
+			ct = enc.Next(ct, frag)
+		}
+		return enc.Finalize(ct)
+	}
+
+	hwCT := seal(hwBlock)
+	pureCT := seal(pureBlock)
+	if !bytes.Equal(hwCT, pureCT) {
+		t.Fatalf("hardware and pure-Go paths disagree:\nhw:   %x\npure: %x", hwCT, pureCT)
+	}
+
+	aead, err := cipher.NewGCM(hwBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := aead.Seal(nil, nonce, pt, ad)
+	if !bytes.Equal(hwCT, want) {
+		t.Fatalf("hardware path disagrees with crypto/cipher.NewGCM:\ngot  %x\nwant %x", hwCT, want)
+	}
+}
+
+// TestStreamingGCMPureGoStreamsIncrementally checks that
+// NewStreamingGCMPureGo, unlike NewStreamingGCM, actually emits ciphertext
+// from Next as full blocks arrive instead of buffering the whole message
+// until Finalize, even when the underlying cipher.Block (crypto/aes) has a
+// hardware-accelerated GCM implementation available. It also checks that the
+// resulting ciphertext matches crypto/cipher's own GCM.
+func TestStreamingGCMPureGoStreamsIncrementally(t *testing.T) {
+	key := randomBytes(t, 16)
+	nonce := randomBytes(t, gcmStandardNonceSize)
+	ad := []byte("associated data")
+	pt := bytes.Repeat([]byte("sgcm pure go streaming "), 50)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, _, err := NewStreamingGCMPureGo(block, gcmStandardNonceSize, gcmTagSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc.Initialize(nonce, ad)
+
+	var ct []byte
+	sawCiphertextBeforeFinalize := false
+	for _, frag := range splitInto(pt, 7) {
+		before := len(ct)
+		ct = enc.Next(ct, frag)
+		if len(ct) > before {
+			sawCiphertextBeforeFinalize = true
+		}
+	}
+	if !sawCiphertextBeforeFinalize {
+		t.Fatal("NewStreamingGCMPureGo did not emit any ciphertext before Finalize")
+	}
+	ct = enc.Finalize(ct)
+
+	hwBlock, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(hwBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := aead.Seal(nil, nonce, pt, ad)
+	if !bytes.Equal(ct, want) {
+		t.Fatalf("NewStreamingGCMPureGo ciphertext disagrees with crypto/cipher.NewGCM:\ngot  %x\nwant %x", ct, want)
+	}
+}
+
+// TestInvalidTagSize checks that tag sizes outside [gcmMinimumTagSize,
+// gcmBlockSize] are rejected.
+func TestInvalidTagSize(t *testing.T) {
+	block, err := aes.NewCipher(randomBytes(t, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []int{0, 1, gcmMinimumTagSize - 1, gcmBlockSize + 1, 32} {
+		if _, _, err := NewStreamingGCMWithTagSize(block, bad); err == nil {
+			t.Fatalf("tagSize %d: expected an error, got nil", bad)
+		}
+	}
+}
+
+// TestTruncatedTagSizes exercises every tag size NIST SP 800-38D permits
+// (12 through 16 bytes). For each, it checks that the ciphertext and the
+// (truncated) tag match what crypto/cipher's own GCM produces, that the
+// round trip through NewStreamingGCMWithTagSize succeeds, and that
+// tampering with the tag is detected.
+func TestTruncatedTagSizes(t *testing.T) {
+	key := randomBytes(t, 16)
+	nonce := randomBytes(t, gcmStandardNonceSize)
+	ad := []byte("associated data")
+	pt := []byte("the quick brown fox jumps over the lazy dog")
+
+	refBlock, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refAEAD, err := cipher.NewGCM(refBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fullSealed := refAEAD.Seal(nil, nonce, pt, ad)
+	wantCT := fullSealed[:len(fullSealed)-gcmTagSize]
+	fullTag := fullSealed[len(fullSealed)-gcmTagSize:]
+
+	for tagSize := gcmMinimumTagSize; tagSize <= gcmBlockSize; tagSize++ {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		enc, dec, err := NewStreamingGCMWithTagSize(block, tagSize)
+		if err != nil {
+			t.Fatalf("tagSize %d: %v", tagSize, err)
+		}
+		if got := enc.TagSize(); got != tagSize {
+			t.Fatalf("tagSize %d: enc.TagSize() = %d", tagSize, got)
+		}
+		if got := dec.TagSize(); got != tagSize {
+			t.Fatalf("tagSize %d: dec.TagSize() = %d", tagSize, got)
+		}
+
+		enc.Initialize(nonce, ad)
+		ct := enc.Next(nil, pt)
+		ct = enc.Finalize(ct)
+
+		gotCT := ct[:len(ct)-tagSize]
+		gotTag := ct[len(ct)-tagSize:]
+		if !bytes.Equal(gotCT, wantCT) {
+			t.Fatalf("tagSize %d: ciphertext mismatch:\ngot  %x\nwant %x", tagSize, gotCT, wantCT)
+		}
+		if !bytes.Equal(gotTag, fullTag[:tagSize]) {
+			t.Fatalf("tagSize %d: tag mismatch:\ngot  %x\nwant %x", tagSize, gotTag, fullTag[:tagSize])
+		}
+
+		dec.Initialize(nonce, ad)
+		plain := dec.Next(nil, gotCT)
+		plain, err = dec.Finalize(plain, gotTag)
+		if err != nil {
+			t.Fatalf("tagSize %d: Finalize: %v", tagSize, err)
+		}
+		if !bytes.Equal(plain, pt) {
+			t.Fatalf("tagSize %d: plaintext mismatch:\ngot  %x\nwant %x", tagSize, plain, pt)
+		}
+
+		tamperedTag := append([]byte{}, gotTag...)
+		tamperedTag[0] ^= 0xff
+		dec.Initialize(nonce, ad)
+		dec.Next(nil, gotCT)
+		if _, err := dec.Finalize(nil, tamperedTag); err == nil {
+			t.Fatalf("tagSize %d: tampered tag was accepted", tagSize)
+		}
+	}
+}
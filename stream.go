@@ -0,0 +1,220 @@
+package sgcm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Segmented streaming AEAD, built on top of AEADEncryptor/AEADDecryptor,
+// following the STREAM construction of Hoang, Reyhanitabar, Rogaway and
+// Vizár (see https://eprint.iacr.org/2015/189.pdf). Unlike the raw
+// AEADDecryptor, which warns that it is not required to prevent release of
+// unverified plaintext, a segment's plaintext is only released once its own
+// tag has verified.
+
+const (
+	// streamNoncePrefixSize is the length of the per-message nonce prefix
+	// supplied to NewStreamWriter/NewStreamReader.
+	streamNoncePrefixSize = 7
+
+	// streamNonceSize is the length of the per-segment nonce: the prefix,
+	// followed by a 4-byte big-endian segment counter and a 1-byte
+	// last-segment flag.
+	streamNonceSize = streamNoncePrefixSize + 4 + 1
+
+	streamMoreSegments = 0x00
+	streamLastSegment  = 0x01
+)
+
+var (
+	errStreamNoncePrefixSize = errors.New("sgcm: nonce prefix must be 7 bytes")
+	errStreamSegmentSize     = errors.New("sgcm: segment size must be positive")
+	errStreamTruncated       = errors.New("sgcm: ciphertext is truncated")
+)
+
+// segmentNonce builds the per-segment nonce prefix || counter || lastFlag.
+func segmentNonce(prefix []byte, counter uint32, last byte) []byte {
+	nonce := make([]byte, streamNonceSize)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[streamNoncePrefixSize:], counter)
+	nonce[streamNonceSize-1] = last
+	return nonce
+}
+
+type streamWriter struct {
+	enc     AEADEncryptor
+	w       io.Writer
+	prefix  []byte
+	segSize int
+	buf     []byte
+	counter uint32
+	closed  bool
+}
+
+// NewStreamWriter returns an io.WriteCloser that encrypts plaintext written
+// to it into segSize-byte segments, each sealed independently by enc and
+// written to w as it fills. prefix is combined with a per-segment counter
+// and a last-segment flag to form each segment's nonce, and so must be
+// unique for every stream sealed under enc's key. Close must be called to
+// flush the final segment (with the last-segment flag set) even if no
+// plaintext was written, so that the receiver can detect truncation.
+func NewStreamWriter(enc AEADEncryptor, w io.Writer, prefix []byte, segSize int) io.WriteCloser {
+	if len(prefix) != streamNoncePrefixSize {
+		panic(errStreamNoncePrefixSize)
+	}
+	if segSize <= 0 {
+		panic(errStreamSegmentSize)
+	}
+	p := make([]byte, streamNoncePrefixSize)
+	copy(p, prefix)
+	return &streamWriter{enc: enc, w: w, prefix: p, segSize: segSize}
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if sw.closed {
+		return 0, errors.New("sgcm: write to closed StreamWriter")
+	}
+
+	n := len(p)
+	sw.buf = append(sw.buf, p...)
+
+	// Only seal once more than a full segment is buffered, so that the
+	// final segment - however short - is always the one sealed in Close
+	// with the last-segment flag set.
+	for len(sw.buf) > sw.segSize {
+		if err := sw.sealSegment(sw.buf[:sw.segSize], streamMoreSegments); err != nil {
+			return 0, err
+		}
+		sw.buf = sw.buf[sw.segSize:]
+	}
+
+	return n, nil
+}
+
+// Close seals and writes the final segment, even if it is empty, and marks
+// the writer as closed.
+func (sw *streamWriter) Close() error {
+	if sw.closed {
+		return nil
+	}
+	sw.closed = true
+	return sw.sealSegment(sw.buf, streamLastSegment)
+}
+
+func (sw *streamWriter) sealSegment(p []byte, last byte) error {
+	nonce := segmentNonce(sw.prefix, sw.counter, last)
+	sw.enc.Initialize(nonce, nil)
+	ct := sw.enc.Next(nil, p)
+	ct = sw.enc.Finalize(ct)
+	sw.counter++
+
+	_, err := sw.w.Write(ct)
+	return err
+}
+
+type streamReader struct {
+	dec     AEADDecryptor
+	r       *bufio.Reader
+	prefix  []byte
+	segSize int
+	tagSize int
+	counter uint32
+	buf     []byte
+	done    bool
+	err     error
+}
+
+// NewStreamReader returns an io.Reader that reads segSize+dec.TagSize()-byte
+// ciphertext segments from r, verifies and decrypts each with dec, and
+// releases its plaintext only once the segment's tag has verified. prefix
+// and segSize must match the values given to the corresponding
+// NewStreamWriter. A segment whose counter does not advance by exactly one,
+// or a stream missing its final (last-flagged) segment, is reported as an
+// error rather than silently accepted.
+func NewStreamReader(dec AEADDecryptor, r io.Reader, prefix []byte, segSize int) io.Reader {
+	if len(prefix) != streamNoncePrefixSize {
+		panic(errStreamNoncePrefixSize)
+	}
+	if segSize <= 0 {
+		panic(errStreamSegmentSize)
+	}
+	p := make([]byte, streamNoncePrefixSize)
+	copy(p, prefix)
+
+	tagSize := dec.TagSize()
+	return &streamReader{
+		dec:     dec,
+		r:       bufio.NewReaderSize(r, segSize+tagSize),
+		prefix:  p,
+		segSize: segSize,
+		tagSize: tagSize,
+	}
+}
+
+func (sr *streamReader) Read(p []byte) (int, error) {
+	for len(sr.buf) == 0 {
+		if sr.err != nil {
+			return 0, sr.err
+		}
+		if sr.done {
+			return 0, io.EOF
+		}
+		if err := sr.readSegment(); err != nil {
+			sr.err = err
+			return 0, err
+		}
+	}
+
+	n := copy(p, sr.buf)
+	sr.buf = sr.buf[n:]
+	return n, nil
+}
+
+// readSegment reads, authenticates and decrypts the next ciphertext
+// segment, appending its plaintext to sr.buf.
+func (sr *streamReader) readSegment() error {
+	ct := make([]byte, sr.segSize+sr.tagSize)
+	n, err := io.ReadFull(sr.r, ct)
+
+	last := byte(streamMoreSegments)
+	switch err {
+	case nil:
+		// A full segment was read; it's the last one iff nothing follows.
+		if _, peekErr := sr.r.Peek(1); peekErr != nil {
+			if peekErr != io.EOF {
+				return peekErr
+			}
+			last = streamLastSegment
+		}
+	case io.ErrUnexpectedEOF:
+		last = streamLastSegment
+	case io.EOF:
+		return errStreamTruncated
+	default:
+		return err
+	}
+
+	ct = ct[:n]
+	if len(ct) < sr.tagSize {
+		return errStreamTruncated
+	}
+	tag := ct[len(ct)-sr.tagSize:]
+	ct = ct[:len(ct)-sr.tagSize]
+
+	nonce := segmentNonce(sr.prefix, sr.counter, last)
+	sr.dec.Initialize(nonce, nil)
+	plain := sr.dec.Next(nil, ct)
+	plain, err = sr.dec.Finalize(plain, tag)
+	if err != nil {
+		return err
+	}
+	sr.counter++
+
+	sr.buf = plain
+	if last == streamLastSegment {
+		sr.done = true
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package sgcm
+
+import (
+	"bytes"
+	"crypto/aes"
+	"io"
+	"testing"
+)
+
+func newAEADPair(t *testing.T) (AEADEncryptor, AEADDecryptor) {
+	t.Helper()
+	key := randomBytes(t, 16)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, _, err := NewStreamingGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block2, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, dec, err := NewStreamingGCM(block2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enc, dec
+}
+
+// TestStreamRoundTrip checks that NewStreamWriter/NewStreamReader round-trip
+// messages of various lengths across a range of segment sizes, including
+// messages that are an exact multiple of the segment size.
+func TestStreamRoundTrip(t *testing.T) {
+	prefix := []byte("prefix7")
+	segSizes := []int{1, 8, 16, 100}
+	messages := [][]byte{
+		nil,
+		[]byte("a"),
+		bytes.Repeat([]byte("x"), 16),
+		bytes.Repeat([]byte("stream"), 500),
+	}
+
+	for _, segSize := range segSizes {
+		for _, msg := range messages {
+			enc, dec := newAEADPair(t)
+
+			var ciphertext bytes.Buffer
+			w := NewStreamWriter(enc, &ciphertext, prefix, segSize)
+			if _, err := w.Write(msg); err != nil {
+				t.Fatalf("segSize %d, len %d: Write: %v", segSize, len(msg), err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("segSize %d, len %d: Close: %v", segSize, len(msg), err)
+			}
+
+			r := NewStreamReader(dec, &ciphertext, prefix, segSize)
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("segSize %d, len %d: ReadAll: %v", segSize, len(msg), err)
+			}
+			if !bytes.Equal(got, msg) {
+				t.Fatalf("segSize %d, len %d: round trip mismatch:\ngot  %x\nwant %x", segSize, len(msg), got, msg)
+			}
+		}
+	}
+}
+
+// TestStreamRejectsTruncation confirms that dropping the final (last-flagged)
+// segment, or any trailing bytes of it, is reported as an error rather than
+// silently accepted as a short message: an attacker who truncates a stream
+// must not be able to make the receiver believe it ended normally.
+func TestStreamRejectsTruncation(t *testing.T) {
+	prefix := []byte("prefix7")
+	segSize := 8
+	msg := bytes.Repeat([]byte("stream"), 10)
+
+	enc, dec := newAEADPair(t)
+	var ciphertext bytes.Buffer
+	w := NewStreamWriter(enc, &ciphertext, prefix, segSize)
+	if _, err := w.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	full := ciphertext.Bytes()
+	for _, cut := range []int{1, len(full) / 2, len(full) - 1} {
+		r := NewStreamReader(dec, bytes.NewReader(full[:cut]), prefix, segSize)
+		if _, err := io.ReadAll(r); err == nil {
+			t.Fatalf("cut at %d of %d: truncated stream was accepted", cut, len(full))
+		}
+	}
+}
+
+// TestStreamRejectsReorderedSegments confirms that swapping two segments on
+// the wire is caught: each segment's nonce is bound to its position via the
+// counter, so a reordered segment fails authentication instead of decrypting
+// into the wrong place in the plaintext.
+func TestStreamRejectsReorderedSegments(t *testing.T) {
+	prefix := []byte("prefix7")
+	segSize := 8
+	msg := bytes.Repeat([]byte("stream"), 10)
+
+	enc, dec := newAEADPair(t)
+	var ciphertext bytes.Buffer
+	w := NewStreamWriter(enc, &ciphertext, prefix, segSize)
+	if _, err := w.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tagSize := dec.TagSize()
+	segLen := segSize + tagSize
+	full := ciphertext.Bytes()
+	if len(full) < 2*segLen {
+		t.Fatalf("test needs at least two full segments, got %d bytes", len(full))
+	}
+
+	// Swap the first two segments.
+	reordered := append([]byte{}, full...)
+	copy(reordered[:segLen], full[segLen:2*segLen])
+	copy(reordered[segLen:2*segLen], full[:segLen])
+
+	r := NewStreamReader(dec, bytes.NewReader(reordered), prefix, segSize)
+	if _, err := io.ReadAll(r); err == nil {
+		t.Fatal("reordered segments were accepted")
+	}
+}
+
+// TestStreamRejectsBadPrefix confirms that a reader given a different nonce
+// prefix than the writer used cannot authenticate the stream, since the
+// prefix is part of every segment's nonce.
+func TestStreamRejectsBadPrefix(t *testing.T) {
+	writePrefix := []byte("prefixA")
+	readPrefix := []byte("prefixB")
+	segSize := 8
+	msg := []byte("mismatched prefixes must not decrypt")
+
+	enc, dec := newAEADPair(t)
+	var ciphertext bytes.Buffer
+	w := NewStreamWriter(enc, &ciphertext, writePrefix, segSize)
+	if _, err := w.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewStreamReader(dec, &ciphertext, readPrefix, segSize)
+	if _, err := io.ReadAll(r); err == nil {
+		t.Fatal("expected authentication failure with mismatched nonce prefix")
+	}
+}